@@ -0,0 +1,217 @@
+package requests
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog"
+	"github.com/tsaron/anansi/webio"
+)
+
+// AccessLog is a sibling of Log: instead of attaching the request/response
+// bodies to a logger built up over the lifetime of the request, it emits a
+// single structured line once the response has been written, in the style
+// of tsweb.StdHandler. The line includes the final status code, response
+// size, client IP (honoring the Forwarded and X-Forwarded-For headers), the
+// matched route template, user-agent, TLS version and a latency bucket.
+func AccessLog() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := webio.NewWriter(w, "", webio.Hooks{})
+			size := &byteCounter{}
+			ww.TeeWriter(size)
+
+			start := time.Now()
+			next.ServeHTTP(ww, r)
+			duration := time.Since(start)
+
+			log := zerolog.Ctx(r.Context())
+			event := log.Info()
+			switch {
+			case ww.Code() >= http.StatusInternalServerError:
+				event = log.Error()
+			case ww.Code() >= http.StatusBadRequest:
+				event = log.Warn()
+			}
+
+			event.
+				Str("method", r.Method).
+				Str("url", r.URL.String()).
+				Str("route", routePattern(r)).
+				Str("client_ip", clientIP(r)).
+				Str("user_agent", r.UserAgent()).
+				Str("tls_version", tlsVersion(r)).
+				Int("status", ww.Code()).
+				Str("status_class", statusClass(ww.Code())).
+				Int64("response_bytes", size.n).
+				Dur("duration", duration).
+				Str("latency_bucket", latencyBucket(duration)).
+				Msg("request completed")
+		})
+	}
+}
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Total number of HTTP requests processed, partitioned by status code, method and route.",
+	}, []string{"code", "method", "route"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Latency of HTTP requests in seconds, partitioned by status code, method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"code", "method", "route"})
+
+	responseSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_response_size_bytes",
+		Help:    "Size of HTTP responses in bytes, partitioned by status code, method and route.",
+		Buckets: prometheus.ExponentialBuckets(100, 10, 6),
+	}, []string{"code", "method", "route"})
+)
+
+// Metrics records Prometheus counters and histograms for every request that
+// passes through it: http_requests_total, http_request_duration_seconds and
+// http_response_size_bytes, all labeled by status code, method and route. It
+// recovers panics, turns them into a 500 response, and still records the
+// metric for that request -- mirroring how webio.StdHandler turns a
+// handler's returned error into both a response and an observable outcome.
+func Metrics() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := webio.NewWriter(w, "", webio.Hooks{})
+			size := &byteCounter{}
+			ww.TeeWriter(size)
+
+			route := metricsRoute(r)
+			start := time.Now()
+
+			defer func() {
+				if rec := recover(); rec != nil {
+					if ww.Code() == 0 {
+						ww.WriteHeader(http.StatusInternalServerError)
+					}
+					zerolog.Ctx(r.Context()).Error().Interface("panic", rec).Msg("recovered from panic")
+				}
+
+				code := strconv.Itoa(ww.Code())
+				requestsTotal.WithLabelValues(code, r.Method, route).Inc()
+				requestDuration.WithLabelValues(code, r.Method, route).Observe(time.Since(start).Seconds())
+				responseSize.WithLabelValues(code, r.Method, route).Observe(float64(size.n))
+			}()
+
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
+// byteCounter is an io.Writer that only counts the bytes written through it,
+// used as a CustomWriter.TeeWriter target to measure response size without
+// buffering the body.
+type byteCounter struct {
+	n int64
+}
+
+func (b *byteCounter) Write(p []byte) (int, error) {
+	b.n += int64(len(p))
+	return len(p), nil
+}
+
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+	return r.URL.Path
+}
+
+// metricsRoute is routePattern, but collapsed to a fixed "not_found" label
+// for any request that didn't match a chi route, since that value otherwise
+// becomes the raw, attacker-controlled request path. Used for Prometheus
+// labels, where an unbounded set of label values is a cardinality blowup --
+// unlike the access log, where the literal path is useful context.
+func metricsRoute(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			return pattern
+		}
+	}
+	return "not_found"
+}
+
+// clientIP returns the client's address, preferring the Forwarded and
+// X-Forwarded-For headers set by a reverse proxy over the raw RemoteAddr.
+func clientIP(r *http.Request) string {
+	if fwd := r.Header.Get("Forwarded"); fwd != "" {
+		for _, part := range strings.Split(fwd, ";") {
+			part = strings.TrimSpace(part)
+			if !strings.HasPrefix(strings.ToLower(part), "for=") {
+				continue
+			}
+
+			ip := strings.Trim(part[len("for="):], `"`)
+			if host, _, err := net.SplitHostPort(ip); err == nil {
+				return host
+			}
+			return ip
+		}
+	}
+
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		return strings.TrimSpace(strings.Split(xff, ",")[0])
+	}
+
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+
+	return r.RemoteAddr
+}
+
+func statusClass(code int) string {
+	return strconv.Itoa(code/100) + "xx"
+}
+
+func tlsVersion(r *http.Request) string {
+	if r.TLS == nil {
+		return ""
+	}
+
+	switch r.TLS.Version {
+	case tls.VersionTLS10:
+		return "TLS1.0"
+	case tls.VersionTLS11:
+		return "TLS1.1"
+	case tls.VersionTLS12:
+		return "TLS1.2"
+	case tls.VersionTLS13:
+		return "TLS1.3"
+	default:
+		return "unknown"
+	}
+}
+
+func latencyBucket(d time.Duration) string {
+	switch {
+	case d < 10*time.Millisecond:
+		return "<10ms"
+	case d < 50*time.Millisecond:
+		return "10-50ms"
+	case d < 100*time.Millisecond:
+		return "50-100ms"
+	case d < 500*time.Millisecond:
+		return "100-500ms"
+	case d < time.Second:
+		return "500ms-1s"
+	default:
+		return ">1s"
+	}
+}
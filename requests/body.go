@@ -0,0 +1,80 @@
+package requests
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// readCloser glues a replacement Reader (typically one that re-prepends
+// already-peeked bytes) to the Closer of the body it replaced.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// cappedBuffer is an io.Writer sink that keeps at most limit bytes, silently
+// discarding anything past that. A negative limit means no cap. It always
+// reports a full write so it can sit behind an io.TeeReader or
+// CustomWriter.TeeWriter without ever causing a short-write error upstream.
+type cappedBuffer struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+
+	if c.limit >= 0 {
+		if remaining := c.limit - c.buf.Len(); remaining < len(p) {
+			if remaining < 0 {
+				remaining = 0
+			}
+			p = p[:remaining]
+		}
+	}
+
+	c.buf.Write(p)
+
+	return n, nil
+}
+
+// errReader is an io.Reader that always fails with err, used to splice a
+// read error back onto the end of a body after its leading bytes have
+// already been consumed by a peek.
+type errReader struct {
+	err error
+}
+
+func (e errReader) Read([]byte) (int, error) {
+	return 0, e.err
+}
+
+// skipBodyType reports whether contentType matches one of types, which may
+// use a "xxx/*" suffix to match an entire media type.
+func skipBodyType(contentType string, types []string) bool {
+	if contentType == "" {
+		return false
+	}
+
+	ct := contentType
+	if i := strings.Index(ct, ";"); i != -1 {
+		ct = ct[:i]
+	}
+	ct = strings.TrimSpace(ct)
+
+	for _, t := range types {
+		if prefix := strings.TrimSuffix(t, "*"); prefix != t {
+			if strings.HasPrefix(ct, prefix) {
+				return true
+			}
+			continue
+		}
+
+		if strings.EqualFold(t, ct) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/middleware"
 	"github.com/rs/zerolog"
+	"github.com/tsaron/anansi/webio"
 )
 
 // Timeout is a middleware that cancels ctx after a given timeout and return
@@ -65,21 +67,39 @@ func AttachLogger(log zerolog.Logger) func(http.Handler) http.Handler {
 	}
 }
 
-type Response interface {
-	// Code returns the response code of the response
-	Code() int
-	// Body returns the body of the request as bytes
-	Body() []byte
+// defaultSkipBodyTypes are content types Log never captures a body for,
+// since they're either unbounded streams or not useful to log as text.
+var defaultSkipBodyTypes = []string{
+	"multipart/*",
+	"application/octet-stream",
+	"text/event-stream",
 }
 
-// Log updates a future log entry with the request parameters such as request ID and headers.
-// Truncates logged request/response size to maxBodySize bytes. Set to less than zero to disable
-// truncation, otherwise it defaults to 8kb
-func Log(maxBodySize int) func(http.Handler) http.Handler {
+// Log updates a future log entry with the request parameters such as request
+// ID, headers, and a capped copy of the request/response bodies. Logged body
+// size is truncated to maxBodySize bytes; set to less than zero to disable
+// truncation, otherwise it defaults to 8kb.
+//
+// Request and response bodies are captured through an io.TeeReader/
+// CustomWriter.TeeWriter into a buffer capped at maxBodySize, so Log is safe
+// to use on streaming endpoints, file uploads, and long-poll/SSE handlers --
+// it never buffers more than maxBodySize bytes, and never waits for the
+// response to finish. Before calling the handler it reads up to maxBodySize
+// bytes of the request body (all of it when maxBodySize is negative), waiting
+// until that many bytes have arrived or the body ends. skipTypes overrides
+// defaultSkipBodyTypes: requests
+// whose Content-Type matches, and responses whose Content-Type matches or
+// whose Content-Encoding is set, skip capture entirely.
+func Log(maxBodySize int, skipTypes ...string) func(http.Handler) http.Handler {
 	if maxBodySize == 0 {
 		maxBodySize = 8 * 1024
 	}
 
+	skip := defaultSkipBodyTypes
+	if len(skipTypes) > 0 {
+		skip = skipTypes
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			log := zerolog.Ctx(r.Context())
@@ -98,30 +118,80 @@ func Log(maxBodySize int) func(http.Handler) http.Handler {
 					Interface("request_headers", toLower(r.Header))
 			})
 
-			requestBody, err := ReadBody(r)
-			if err != nil {
-				panic(err)
+			// Peek at up to maxBodySize bytes of the request body up front (so
+			// the "request" field is available for any logging the handler
+			// itself does), then splice those bytes back in front of the
+			// rest of the original body so the handler still sees the whole
+			// stream. Unlike buffering the entire body, this reads at most
+			// maxBodySize bytes (all of it when maxBodySize is negative)
+			// before handing control to the handler, so on uploads or
+			// long-lived request bodies it waits only for that many bytes.
+			var reqBuf *cappedBuffer
+			if r.Body != nil && !skipBodyType(r.Header.Get("Content-Type"), skip) {
+				reqBuf = &cappedBuffer{limit: maxBodySize}
+
+				peekable := io.Reader(r.Body)
+				if maxBodySize >= 0 {
+					peekable = io.LimitReader(r.Body, int64(maxBodySize))
+				}
+
+				// A read error here (client disconnect mid-upload, broken
+				// chunked encoding, etc.) is routine and shouldn't take down
+				// the request: capture whatever was read before the error,
+				// and let the handler's own read of r.Body hit the same
+				// error when it gets past the peeked bytes.
+				peeked, err := io.ReadAll(io.TeeReader(peekable, reqBuf))
+				var rest io.Reader = r.Body
+				if err != nil {
+					rest = errReader{err}
+				}
+
+				r.Body = readCloser{
+					Reader: io.MultiReader(bytes.NewReader(peeked), rest),
+					Closer: r.Body,
+				}
 			}
 
-			if len(requestBody) != 0 {
+			if reqBuf != nil && reqBuf.buf.Len() > 0 {
+				data, valid := logBody(maxBodySize, reqBuf.buf.Bytes())
 				log.UpdateContext(func(ctx zerolog.Context) zerolog.Context {
-					buffer := logBody(log, maxBodySize, requestBody)
-					return ctx.RawJSON("request", buffer.Bytes())
+					if valid {
+						return ctx.RawJSON("request", data)
+					}
+					return ctx.Str("request", string(data))
 				})
 			}
 
+			var (
+				ww      webio.CustomWriter
+				respBuf *cappedBuffer
+			)
+			ww = webio.NewWriter(w, "", webio.Hooks{
+				WriteHeader: func(next webio.WriteHeaderFunc) webio.WriteHeaderFunc {
+					return func(code int) {
+						header := w.Header()
+						if header.Get("Content-Encoding") == "" && !skipBodyType(header.Get("Content-Type"), skip) {
+							respBuf = &cappedBuffer{limit: maxBodySize}
+							ww.TeeWriter(respBuf)
+						}
+						next(code)
+					}
+				},
+			})
+
 			defer func() {
-				ww, ok := w.(Response)
-				if !ok {
-					return
+				if respBuf != nil && respBuf.buf.Len() > 0 {
+					data, valid := logBody(maxBodySize, respBuf.buf.Bytes())
+					log.UpdateContext(func(ctx zerolog.Context) zerolog.Context {
+						if valid {
+							return ctx.RawJSON("response", data)
+						}
+						return ctx.Str("response", string(data))
+					})
 				}
-				log.UpdateContext(func(ctx zerolog.Context) zerolog.Context {
-					buffer := logBody(log, maxBodySize, ww.Body())
-					return ctx.RawJSON("response", buffer.Bytes())
-				})
 			}()
 
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(ww, r)
 		})
 	}
 }
@@ -143,18 +213,26 @@ func toLower(headers http.Header) map[string]interface{} {
 	return lowerCaseHeaders
 }
 
-func logBody(log *zerolog.Logger, maxSize int, body []byte) *bytes.Buffer {
+// logBody compacts body for logging and reports whether the result is still
+// valid JSON, so callers can choose between ctx.RawJSON (valid JSON, emitted
+// unescaped) and ctx.Str (anything else, safely escaped). body may have been
+// cut off mid-token by the capture cap -- json.Compact fails in that case,
+// and the raw, possibly invalid bytes are returned instead of being dropped.
+// Likewise, truncating a large-but-valid body for length breaks its JSON
+// validity, so that path reports invalid too.
+func logBody(maxSize int, body []byte) (data []byte, valid bool) {
 	buffer := new(bytes.Buffer)
 
 	if err := json.Compact(buffer, body); err != nil {
-		panic(err)
+		return body, false
 	}
 
 	// only truncate large requests
 	if maxSize > 0 && buffer.Len() > maxSize {
 		buffer.Truncate(maxSize - 3) // leave space for the elipsis(3 bytes)
 		buffer.WriteString("...")
+		return buffer.Bytes(), false
 	}
 
-	return buffer
+	return buffer.Bytes(), true
 }
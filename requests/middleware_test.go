@@ -2,6 +2,7 @@ package requests
 
 import (
 	"bytes"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -89,7 +90,9 @@ func TestLog(t *testing.T) {
 
 	router.Use(AttachLogger(logger))
 	router.With(Log(0)).Post("/", func(w http.ResponseWriter, r *http.Request) {
-		// write to buffer
+		// Log has already peeked at the body; drain the rest as a real handler would
+		_, _ = io.Copy(io.Discard, r.Body)
+
 		log := zerolog.Ctx(r.Context())
 		log.Info().Msg("")
 
@@ -97,7 +100,8 @@ func TestLog(t *testing.T) {
 	})
 
 	router.With(Log(1024)).Post("/bigrequest", func(w http.ResponseWriter, r *http.Request) {
-		// write to buffer
+		_, _ = io.Copy(io.Discard, r.Body)
+
 		log := zerolog.Ctx(r.Context())
 		log.Info().Msg("")
 
@@ -150,10 +154,13 @@ func TestLog(t *testing.T) {
 
 	t.Run("truncates large request body", func(t *testing.T) {
 		type requestLog struct {
-			URL     string   `json:"url"`
-			Method  string   `json:"method"`
-			Address string   `json:"remote_address"`
-			Body    []string `json:"request"` // because the request itself is an array
+			URL     string `json:"url"`
+			Method  string `json:"method"`
+			Address string `json:"remote_address"`
+			// the capture cap cuts the request mid-array, so it's no longer
+			// valid JSON and Log logs it as an escaped string instead of
+			// risking a corrupted log line.
+			Body string `json:"request"`
 		}
 
 		defer logOut.Reset()
@@ -170,7 +177,9 @@ func TestLog(t *testing.T) {
 		router.ServeHTTP(res, req)
 
 		logs := requestLog{}
-		_ = json.Unmarshal(logOut.Bytes(), &logs)
+		if err := json.Unmarshal(logOut.Bytes(), &logs); err != nil {
+			t.Fatalf("Expected log line to be valid JSON, got error %v for %s", err, logOut.String())
+		}
 
 		if logs.URL == "" {
 			t.Error("Expected URL to be logged")
@@ -184,9 +193,8 @@ func TestLog(t *testing.T) {
 			t.Error("Expected request address to be logged")
 		}
 
-		// we can't expect 1024 due to how the log is parsed back into JSON
-		if len(logs.Body[0]) > 1024 {
-			t.Errorf("Expected logged request body to be %d, got %d", 1024, len(logs.Body[0]))
+		if len(logs.Body) > 1024 {
+			t.Errorf("Expected logged request body to be at most %d bytes, got %d", 1024, len(logs.Body))
 		}
 	})
 }
@@ -0,0 +1,29 @@
+package requests
+
+import "net/http"
+
+// RealIP overwrites r.RemoteAddr with the client address reported by the
+// Forwarded/X-Forwarded-For headers (the same ones clientIP checks for
+// AccessLog), so that r.RemoteAddr -- and therefore Log's remote_address
+// field -- is still meaningful when this process isn't the one terminating
+// the client's connection: behind a reverse proxy, or served over FastCGI/
+// CGI via webio.ServeFCGI/ServeCGI, where REMOTE_ADDR is the front-end web
+// server, not the client. Modeled after
+// github.com/go-chi/chi/middleware.RealIP.
+//
+// Only use this behind a front-end you trust to set these headers
+// truthfully; they're trivial for a direct client to spoof otherwise.
+func RealIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Forwarded-For") == "" && r.Header.Get("Forwarded") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if ip := clientIP(r); ip != "" {
+			r.RemoteAddr = ip
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,320 @@
+package webio
+
+// Compress implements response compression the way it needs to be done to
+// avoid the bug gorilla/handlers' CompressHandlerLevel has: wrapping a
+// ResponseWriter in a type that unconditionally implements http.Flusher,
+// http.Hijacker, http.Pusher and io.ReaderFrom, regardless of whether the
+// writer it wraps actually supports them, so a type assertion against the
+// wrapped writer lies. Instead, like the CustomWriter combos in
+// writer_combos.go, the optional interfaces compressWriter exposes are
+// computed once from what the writer passed to Compress's middleware
+// implements, and dispatched through compress_combos.go.
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/rs/zerolog"
+)
+
+// minCompressSize is the smallest response body Compress will bother
+// encoding; smaller responses are written through unmodified since the
+// compression overhead isn't worth it.
+const minCompressSize = 1024
+
+var defaultCompressTypes = []string{
+	"text/html",
+	"text/css",
+	"text/plain",
+	"text/xml",
+	"text/javascript",
+	"application/javascript",
+	"application/json",
+	"application/xml",
+	"image/svg+xml",
+}
+
+// Compress returns a middleware that negotiates Accept-Encoding and
+// transparently gzip-, deflate- or brotli-encodes responses whose
+// Content-Type is in types (defaultCompressTypes if none are given) and
+// whose body is at least minCompressSize bytes. level is handed to each
+// encoder unchanged: gzip and deflate read it on compress/gzip's scale,
+// brotli on its own 0-11 quality scale.
+//
+// TeeWriter on the CustomWriter passed to the wrapped handler keeps
+// observing the uncompressed response; only the bytes actually written to
+// the network go through the encoder. The status code is held back until
+// minCompressSize bytes are buffered, the handler flushes, or it returns,
+// so Code and Duration report that later moment and Code is 0 until then.
+func Compress(level int, types ...string) func(http.Handler) http.Handler {
+	allow := defaultCompressTypes
+	if len(types) > 0 {
+		allow = types
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ww, ok := w.(CustomWriter)
+			if !ok {
+				ww = NewWriter(w, "", Hooks{})
+			}
+
+			cw := &compressWriter{ww: ww, level: level, allow: allow, encoding: encoding}
+
+			id := 0
+			if _, ok := ww.(http.Flusher); ok {
+				id |= 1
+			}
+			if _, ok := ww.(http.Hijacker); ok {
+				id |= 2
+			}
+			if _, ok := ww.(io.ReaderFrom); ok {
+				id |= 4
+			}
+			if _, ok := ww.(http.Pusher); ok {
+				id |= 8
+			}
+
+			next.ServeHTTP(pickCompress(id, cw), r)
+
+			if err := cw.Close(); err != nil {
+				zerolog.Ctx(r.Context()).Error().Err(err).Msg("failed to close compressed response")
+			}
+		})
+	}
+}
+
+type compressWriter struct {
+	ww       CustomWriter
+	level    int
+	allow    []string
+	encoding string
+
+	pendingCode int
+	committed   bool
+
+	decided  bool
+	compress bool
+	encoder  io.WriteCloser
+	buf      []byte
+
+	tee io.Writer
+}
+
+func (c *compressWriter) Header() http.Header     { return c.ww.Header() }
+func (c *compressWriter) Code() int               { return c.ww.Code() }
+func (c *compressWriter) Duration() time.Duration { return c.ww.Duration() }
+func (c *compressWriter) TeeWriter(tee io.Writer) { c.tee = tee }
+
+func (c *compressWriter) WriteHeader(code int) {
+	if c.pendingCode == 0 {
+		c.pendingCode = code
+	}
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+	if c.tee != nil {
+		_, _ = c.tee.Write(p)
+	}
+
+	if !c.decided {
+		c.buf = append(c.buf, p...)
+		if len(c.buf) < minCompressSize {
+			return len(p), nil
+		}
+
+		c.decide()
+		c.commit()
+
+		buffered := c.buf
+		c.buf = nil
+
+		if err := c.writeEncoded(buffered); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	if err := c.writeEncoded(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *compressWriter) writeEncoded(p []byte) error {
+	if len(p) == 0 {
+		return nil
+	}
+	if c.compress {
+		_, err := c.encoder.Write(p)
+		return err
+	}
+	_, err := c.ww.Write(p)
+	return err
+}
+
+// decide picks whether this response gets compressed, based on its size and
+// Content-Type, and sets up the encoder if so. Called once either the
+// buffered body reaches minCompressSize or the response ends -- in the
+// latter case the buffer may still be under minCompressSize, so the size
+// check has to happen here rather than only at the call site in Write.
+func (c *compressWriter) decide() {
+	c.decided = true
+
+	if len(c.buf) < minCompressSize || !compressibleType(c.Header().Get("Content-Type"), c.allow) {
+		return
+	}
+
+	c.compress = true
+
+	h := c.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", c.encoding)
+	h.Add("Vary", "Accept-Encoding")
+
+	switch c.encoding {
+	case "br":
+		c.encoder = brotli.NewWriterLevel(c.ww, c.level)
+	case "gzip":
+		gz, err := gzip.NewWriterLevel(c.ww, c.level)
+		if err != nil {
+			gz, _ = gzip.NewWriterLevel(c.ww, gzip.DefaultCompression)
+		}
+		c.encoder = gz
+	case "deflate":
+		fl, err := flate.NewWriter(c.ww, c.level)
+		if err != nil {
+			fl, _ = flate.NewWriter(c.ww, flate.DefaultCompression)
+		}
+		c.encoder = fl
+	}
+}
+
+func (c *compressWriter) commit() {
+	if c.committed {
+		return
+	}
+	c.committed = true
+
+	code := c.pendingCode
+	if code == 0 {
+		code = http.StatusOK
+	}
+	c.ww.WriteHeader(code)
+}
+
+// flush is invoked by the Flusher combo mixin. It forces a decision (and
+// header commit) on whatever has been buffered so far, then flushes the
+// encoder and the underlying writer.
+func (c *compressWriter) flush() {
+	if !c.decided {
+		c.decide()
+		c.commit()
+		buffered := c.buf
+		c.buf = nil
+		_ = c.writeEncoded(buffered)
+	}
+
+	if c.compress {
+		if f, ok := c.encoder.(interface{ Flush() error }); ok {
+			_ = f.Flush()
+		}
+	}
+
+	if f, ok := c.ww.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close flushes any response smaller than minCompressSize through
+// uncompressed, or closes the encoder for one that was compressed. It's
+// called once the wrapped handler returns.
+func (c *compressWriter) Close() error {
+	if !c.decided {
+		c.decide()
+		c.commit()
+		buffered := c.buf
+		c.buf = nil
+		if err := c.writeEncoded(buffered); err != nil {
+			return err
+		}
+	}
+
+	if c.compress {
+		return c.encoder.Close()
+	}
+	return nil
+}
+
+func compressibleType(contentType string, allow []string) bool {
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	if i := strings.Index(contentType, ";"); i != -1 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.TrimSpace(contentType)
+
+	for _, t := range allow {
+		if strings.EqualFold(t, contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateEncoding picks the best supported encoding (brotli, then gzip,
+// then deflate) out of the client's Accept-Encoding header, honoring
+// q-values. Returns "" if the client doesn't accept any of them.
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	preference := map[string]int{"br": 3, "gzip": 2, "deflate": 1}
+
+	best := ""
+	bestQ, bestRank := 0.0, 0
+
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name := part
+		q := 1.0
+
+		if i := strings.Index(part, ";"); i != -1 {
+			name = strings.TrimSpace(part[:i])
+			if qi := strings.Index(part[i+1:], "q="); qi != -1 {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(part[i+1+qi+2:]), 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		rank, ok := preference[name]
+		if !ok || q <= 0 {
+			continue
+		}
+
+		if q > bestQ || (q == bestQ && rank > bestRank) {
+			best, bestQ, bestRank = name, q, rank
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,183 @@
+package webio
+
+// Mechanically derived combinations of the optional interfaces a
+// compressWriter may need to pass through (http.Flusher, http.Hijacker,
+// io.ReaderFrom, http.Pusher), mirroring the approach in writer_combos.go.
+// See Compress in compress.go for how the bitmask is computed and
+// dispatched via pickCompress.
+//
+// bit 1<<0 = http.Flusher, 1<<1 = http.Hijacker, 1<<2 = io.ReaderFrom,
+// 1<<3 = http.Pusher
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+type cFlusher struct{ *compressWriter }
+
+func (f cFlusher) Flush() { f.flush() }
+
+type cHijacker struct{ *compressWriter }
+
+func (h cHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return h.ww.(http.Hijacker).Hijack()
+}
+
+type cReaderFrom struct{ *compressWriter }
+
+// ReadFrom copies src through the usual Write path (and therefore through
+// the encoder, if this response ends up compressed) rather than handing src
+// to the underlying writer's ReadFrom, since that would bypass compression
+// entirely.
+func (r cReaderFrom) ReadFrom(src io.Reader) (int64, error) {
+	return io.Copy(r.compressWriter, src)
+}
+
+type cPusher struct{ *compressWriter }
+
+func (p cPusher) Push(target string, opts *http.PushOptions) error {
+	return p.ww.(http.Pusher).Push(target, opts)
+}
+
+type compressCombo00 struct {
+	*compressWriter
+}
+
+type compressCombo01 struct {
+	*compressWriter
+	cFlusher
+}
+
+type compressCombo02 struct {
+	*compressWriter
+	cHijacker
+}
+
+type compressCombo03 struct {
+	*compressWriter
+	cFlusher
+	cHijacker
+}
+
+type compressCombo04 struct {
+	*compressWriter
+	cReaderFrom
+}
+
+type compressCombo05 struct {
+	*compressWriter
+	cFlusher
+	cReaderFrom
+}
+
+type compressCombo06 struct {
+	*compressWriter
+	cHijacker
+	cReaderFrom
+}
+
+type compressCombo07 struct {
+	*compressWriter
+	cFlusher
+	cHijacker
+	cReaderFrom
+}
+
+type compressCombo08 struct {
+	*compressWriter
+	cPusher
+}
+
+type compressCombo09 struct {
+	*compressWriter
+	cFlusher
+	cPusher
+}
+
+type compressCombo10 struct {
+	*compressWriter
+	cHijacker
+	cPusher
+}
+
+type compressCombo11 struct {
+	*compressWriter
+	cFlusher
+	cHijacker
+	cPusher
+}
+
+type compressCombo12 struct {
+	*compressWriter
+	cReaderFrom
+	cPusher
+}
+
+type compressCombo13 struct {
+	*compressWriter
+	cFlusher
+	cReaderFrom
+	cPusher
+}
+
+type compressCombo14 struct {
+	*compressWriter
+	cHijacker
+	cReaderFrom
+	cPusher
+}
+
+type compressCombo15 struct {
+	*compressWriter
+	cFlusher
+	cHijacker
+	cReaderFrom
+	cPusher
+}
+
+// pickCompress returns the CustomWriter combo type matching the given
+// bitmask of optional interfaces, so a type assertion against the result
+// only succeeds for interfaces the writer passed to Compress actually
+// implements.
+func pickCompress(id int, cw *compressWriter) CustomWriter {
+	switch id {
+	case 0:
+		return &compressCombo00{compressWriter: cw}
+	case 1:
+		return &compressCombo01{compressWriter: cw, cFlusher: cFlusher{compressWriter: cw}}
+	case 2:
+		return &compressCombo02{compressWriter: cw, cHijacker: cHijacker{compressWriter: cw}}
+	case 3:
+		return &compressCombo03{compressWriter: cw, cFlusher: cFlusher{compressWriter: cw}, cHijacker: cHijacker{compressWriter: cw}}
+	case 4:
+		return &compressCombo04{compressWriter: cw, cReaderFrom: cReaderFrom{compressWriter: cw}}
+	case 5:
+		return &compressCombo05{compressWriter: cw, cFlusher: cFlusher{compressWriter: cw}, cReaderFrom: cReaderFrom{compressWriter: cw}}
+	case 6:
+		return &compressCombo06{compressWriter: cw, cHijacker: cHijacker{compressWriter: cw}, cReaderFrom: cReaderFrom{compressWriter: cw}}
+	case 7:
+		return &compressCombo07{compressWriter: cw, cFlusher: cFlusher{compressWriter: cw}, cHijacker: cHijacker{compressWriter: cw}, cReaderFrom: cReaderFrom{compressWriter: cw}}
+	case 8:
+		return &compressCombo08{compressWriter: cw, cPusher: cPusher{compressWriter: cw}}
+	case 9:
+		return &compressCombo09{compressWriter: cw, cFlusher: cFlusher{compressWriter: cw}, cPusher: cPusher{compressWriter: cw}}
+	case 10:
+		return &compressCombo10{compressWriter: cw, cHijacker: cHijacker{compressWriter: cw}, cPusher: cPusher{compressWriter: cw}}
+	case 11:
+		return &compressCombo11{compressWriter: cw, cFlusher: cFlusher{compressWriter: cw}, cHijacker: cHijacker{compressWriter: cw}, cPusher: cPusher{compressWriter: cw}}
+	case 12:
+		return &compressCombo12{compressWriter: cw, cReaderFrom: cReaderFrom{compressWriter: cw}, cPusher: cPusher{compressWriter: cw}}
+	case 13:
+		return &compressCombo13{compressWriter: cw, cFlusher: cFlusher{compressWriter: cw}, cReaderFrom: cReaderFrom{compressWriter: cw}, cPusher: cPusher{compressWriter: cw}}
+	case 14:
+		return &compressCombo14{compressWriter: cw, cHijacker: cHijacker{compressWriter: cw}, cReaderFrom: cReaderFrom{compressWriter: cw}, cPusher: cPusher{compressWriter: cw}}
+	case 15:
+		return &compressCombo15{compressWriter: cw, cFlusher: cFlusher{compressWriter: cw}, cHijacker: cHijacker{compressWriter: cw}, cReaderFrom: cReaderFrom{compressWriter: cw}, cPusher: cPusher{compressWriter: cw}}
+	default:
+		return cw
+	}
+}
+
@@ -0,0 +1,164 @@
+package webio
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"empty header accepts nothing", "", ""},
+		{"single encoding", "gzip", "gzip"},
+		{"brotli preferred over gzip when both unweighted", "gzip, br", "br"},
+		{"q-values override the default preference order", "br;q=0.1, gzip;q=0.9", "gzip"},
+		{"zero q-value is excluded", "br;q=0", ""},
+		{"unsupported encoding is ignored", "identity, compress", ""},
+		{"deflate is the lowest-ranked fallback", "deflate", "deflate"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := negotiateEncoding(c.header); got != c.want {
+				t.Errorf("negotiateEncoding(%q) = %q, want %q", c.header, got, c.want)
+			}
+		})
+	}
+}
+
+func TestCompressibleType(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+		want        bool
+	}{
+		{"default types include text/html", "text/html", true},
+		{"parameters are stripped before matching", "text/html; charset=utf-8", true},
+		{"empty content type falls back to text/plain", "", true},
+		{"image types aren't compressed by default", "image/png", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := compressibleType(c.contentType, defaultCompressTypes); got != c.want {
+				t.Errorf("compressibleType(%q) = %v, want %v", c.contentType, got, c.want)
+			}
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat("a", minCompressSize*2)
+
+	t.Run("compresses a large, allowed response when the client accepts gzip", func(t *testing.T) {
+		h := Compress(gzip.DefaultCompression)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte(body))
+		}))
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		res := httptest.NewRecorder()
+
+		h.ServeHTTP(res, req)
+
+		if res.Header().Get("Content-Encoding") != "gzip" {
+			t.Fatalf("expected Content-Encoding: gzip, got %q", res.Header().Get("Content-Encoding"))
+		}
+
+		gr, err := gzip.NewReader(res.Body)
+		if err != nil {
+			t.Fatalf("expected a valid gzip stream, got error %v", err)
+		}
+		got, err := io.ReadAll(gr)
+		if err != nil {
+			t.Fatalf("failed to read gzip stream: %v", err)
+		}
+		if string(got) != body {
+			t.Errorf("expected decompressed body to round-trip, got %d bytes", len(got))
+		}
+	})
+
+	t.Run("passes the response through unmodified when the client sends no Accept-Encoding", func(t *testing.T) {
+		h := Compress(gzip.DefaultCompression)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte(body))
+		}))
+
+		req := httptest.NewRequest("GET", "/", nil)
+		res := httptest.NewRecorder()
+
+		h.ServeHTTP(res, req)
+
+		if res.Header().Get("Content-Encoding") != "" {
+			t.Errorf("expected no Content-Encoding, got %q", res.Header().Get("Content-Encoding"))
+		}
+		if res.Body.String() != body {
+			t.Errorf("expected the body to pass through unmodified")
+		}
+	})
+
+	t.Run("a response smaller than minCompressSize is left uncompressed", func(t *testing.T) {
+		small := "too small to bother"
+
+		h := Compress(gzip.DefaultCompression)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte(small))
+		}))
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		res := httptest.NewRecorder()
+
+		h.ServeHTTP(res, req)
+
+		if res.Header().Get("Content-Encoding") != "" {
+			t.Errorf("expected no Content-Encoding for a small response, got %q", res.Header().Get("Content-Encoding"))
+		}
+		if res.Body.String() != small {
+			t.Errorf("expected the small body to pass through unmodified, got %q", res.Body.String())
+		}
+	})
+
+	t.Run("a disallowed Content-Type is left uncompressed", func(t *testing.T) {
+		h := Compress(gzip.DefaultCompression)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write([]byte(body))
+		}))
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		res := httptest.NewRecorder()
+
+		h.ServeHTTP(res, req)
+
+		if res.Header().Get("Content-Encoding") != "" {
+			t.Errorf("expected no Content-Encoding for a disallowed type, got %q", res.Header().Get("Content-Encoding"))
+		}
+	})
+
+	t.Run("Flush on the wrapped writer still reaches the underlying ResponseWriter", func(t *testing.T) {
+		h := Compress(gzip.DefaultCompression)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte(body))
+			w.(http.Flusher).Flush()
+		}))
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		res := httptest.NewRecorder()
+
+		h.ServeHTTP(res, req)
+
+		if !res.Flushed {
+			t.Error("expected the underlying ResponseRecorder to observe a Flush")
+		}
+	})
+}
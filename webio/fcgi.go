@@ -0,0 +1,32 @@
+package webio
+
+// These let an anansi-based handler run behind a front-end web server
+// (nginx, Apache) that speaks FastCGI or plain CGI instead of proxying
+// plain HTTP. No real http.Server is involved in either case, but that's
+// transparent to the rest of the stack: NewWriter's combo dispatch (see
+// writer.go/writer_combos.go) already only advertises the optional
+// interfaces the ResponseWriter it's given actually implements, so a
+// FastCGI response -- which implements http.Flusher but never
+// http.Hijacker, since there's no hijackable connection, just a
+// multiplexed FastCGI record stream -- still gets the response-time header
+// and a correct CustomWriter without any FastCGI-specific code here.
+
+import (
+	"net"
+	"net/http"
+	"net/http/cgi"
+	"net/http/fcgi"
+)
+
+// ServeFCGI serves h over FastCGI on l, e.g. behind nginx's fastcgi_pass or
+// Apache's mod_proxy_fcgi.
+func ServeFCGI(l net.Listener, h http.Handler) error {
+	return fcgi.Serve(l, h)
+}
+
+// ServeCGI serves h as a single CGI request/response over stdin/stdout/the
+// environment, for front-end web servers that spawn a process per request
+// instead of speaking FastCGI.
+func ServeCGI(h http.Handler) error {
+	return cgi.Serve(h)
+}
@@ -0,0 +1,185 @@
+package webio
+
+// This follows the error-return handler pattern popularised by Go Kit and
+// cmd/tsweb's StdHandler: a handler returns an error instead of writing one
+// itself, and a single adapter turns that error into the right status code,
+// a safe response body, and a log line -- so callers stop hand-rolling
+// WriteHeader/Write calls for the error path in every handler.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/rs/zerolog"
+	"github.com/tsaron/anansi/responses"
+)
+
+// StatusClientClosedRequest is nginx's non-standard but widely adopted code
+// for "the client disconnected before the response was ready", used when a
+// ReturnHandler's context was canceled by the client rather than timing out.
+const StatusClientClosedRequest = 499
+
+// ReturnHandler is like http.Handler, except ServeHTTPReturn returns an
+// error instead of writing one to w itself. Use StdHandler to adapt a
+// ReturnHandler into an http.Handler.
+type ReturnHandler interface {
+	ServeHTTPReturn(w http.ResponseWriter, r *http.Request) error
+}
+
+// ReturnHandlerFunc adapts a plain func to a ReturnHandler.
+type ReturnHandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// ServeHTTPReturn calls f(w, r).
+func (f ReturnHandlerFunc) ServeHTTPReturn(w http.ResponseWriter, r *http.Request) error {
+	return f(w, r)
+}
+
+// HTTPError is an error that carries the status code and the user-facing
+// message StdHandler should respond with. Err, if set, is the underlying
+// cause that gets logged but never sent to the client.
+type HTTPError struct {
+	Code int
+	Msg  string
+	Err  error
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return e.Msg
+}
+
+// Unwrap lets errors.Is/errors.As see through to Err.
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// safeError marks its msg as safe to expose to the client, independently of
+// whatever its wrapped err says in Error(). Modeled after
+// github.com/egtann/vizerror, which solves the same "which errors are OK to
+// show a user" problem.
+type safeError struct {
+	err error
+	msg string
+}
+
+// Safe wraps err so that StdHandler responds with msg instead of the
+// generic status text, while err itself is still what gets logged. Use this
+// to mark an error as safe to show a client without resorting to an
+// HTTPError when the status code should stay whatever it would otherwise be.
+func Safe(err error, msg string) error {
+	return &safeError{err: err, msg: msg}
+}
+
+func (e *safeError) Error() string { return e.err.Error() }
+func (e *safeError) Unwrap() error { return e.err }
+func (e *safeError) safe() string  { return e.msg }
+
+type safeMessager interface {
+	safe() string
+}
+
+// StdHandlerOptions configures StdHandler.
+type StdHandlerOptions struct {
+	// DefaultCode is the status code used for errors that don't carry an
+	// explicit one via HTTPError. Defaults to http.StatusInternalServerError.
+	DefaultCode int
+}
+
+// StdHandler adapts h into an http.Handler that centralizes error handling.
+// If h returns an error, StdHandler picks a status code (honoring an
+// *HTTPError's Code, falling back to opts.DefaultCode), picks a response
+// message (honoring an error wrapped with Safe, falling back to the status
+// text), writes that as a JSON body via responses.Write, and logs the
+// underlying error on the logger attached to the request context by
+// AttachLogger.
+//
+// A context canceled by the client (as opposed to timing out) maps to
+// StatusClientClosedRequest, and context.DeadlineExceeded maps to
+// StatusGatewayTimeout, so that this composes with Timeout: once the
+// deadline set by Timeout fires, the handler's returned error becomes the
+// response. This only works if the handler hasn't already written a
+// response -- once it has, that response is left as it is and the error is
+// only logged. Same caveat Timeout itself documents: the handler must select
+// on ctx.Done() to stop early.
+func StdHandler(h ReturnHandler, opts StdHandlerOptions) http.Handler {
+	defaultCode := opts.DefaultCode
+	if defaultCode == 0 {
+		defaultCode = http.StatusInternalServerError
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := NewWriter(w, "", Hooks{})
+
+		err := h.ServeHTTPReturn(ww, r)
+		if err == nil {
+			err = r.Context().Err()
+			if err == nil {
+				return
+			}
+		}
+
+		if ww.Code() != 0 {
+			// a response was already committed, nothing left to do but log.
+			logError(r, err, ww.Code())
+			return
+		}
+
+		code, msg := errorResponse(err, defaultCode)
+		logError(r, err, code)
+
+		body, marshalErr := json.Marshal(errorBody{Error: msg})
+		if marshalErr != nil {
+			panic(marshalErr)
+		}
+
+		responses.Write(ww, code, body)
+	})
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+func errorResponse(err error, defaultCode int) (code int, msg string) {
+	code = defaultCode
+	msg = http.StatusText(defaultCode)
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		if httpErr.Code != 0 {
+			code = httpErr.Code
+		}
+		if httpErr.Msg != "" {
+			msg = httpErr.Msg
+		}
+	}
+
+	var safe safeMessager
+	if errors.As(err, &safe) {
+		msg = safe.safe()
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		code = StatusClientClosedRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		code = http.StatusGatewayTimeout
+	}
+
+	return code, msg
+}
+
+func logError(r *http.Request, err error, code int) {
+	log := zerolog.Ctx(r.Context())
+
+	event := log.Error()
+	if code < http.StatusInternalServerError {
+		event = log.Warn()
+	}
+
+	event.Err(err).Int("status", code).Msg("request returned an error")
+}
@@ -0,0 +1,132 @@
+package webio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestStdHandler(t *testing.T) {
+	newRequest := func() *http.Request {
+		logger := zerolog.New(&bytes.Buffer{})
+		r := httptest.NewRequest("GET", "/", nil)
+		return r.WithContext(logger.WithContext(r.Context()))
+	}
+
+	cases := []struct {
+		name       string
+		err        error
+		wantCode   int
+		wantErrMsg string
+	}{
+		{
+			name:       "HTTPError carries its own code and message",
+			err:        &HTTPError{Code: http.StatusNotFound, Msg: "no such thing"},
+			wantCode:   http.StatusNotFound,
+			wantErrMsg: "no such thing",
+		},
+		{
+			name:       "Safe overrides the message but keeps the default code",
+			err:        Safe(errors.New("db exploded"), "something went wrong"),
+			wantCode:   http.StatusInternalServerError,
+			wantErrMsg: "something went wrong",
+		},
+		{
+			name:       "plain error falls back to status text at the default code",
+			err:        errors.New("boom"),
+			wantCode:   http.StatusInternalServerError,
+			wantErrMsg: http.StatusText(http.StatusInternalServerError),
+		},
+		{
+			name:       "context.Canceled maps to StatusClientClosedRequest",
+			err:        context.Canceled,
+			wantCode:   StatusClientClosedRequest,
+			wantErrMsg: http.StatusText(http.StatusInternalServerError),
+		},
+		{
+			name:       "context.DeadlineExceeded maps to a gateway timeout",
+			err:        context.DeadlineExceeded,
+			wantCode:   http.StatusGatewayTimeout,
+			wantErrMsg: http.StatusText(http.StatusInternalServerError),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			h := StdHandler(ReturnHandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+				return c.err
+			}), StdHandlerOptions{})
+
+			res := httptest.NewRecorder()
+			h.ServeHTTP(res, newRequest())
+
+			if res.Code != c.wantCode {
+				t.Errorf("expected status %d, got %d", c.wantCode, res.Code)
+			}
+
+			var body errorBody
+			if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil {
+				t.Fatalf("expected a valid JSON error body, got error %v for %s", err, res.Body.String())
+			}
+
+			if body.Error != c.wantErrMsg {
+				t.Errorf("expected error message %q, got %q", c.wantErrMsg, body.Error)
+			}
+		})
+	}
+
+	t.Run("a response already written is left alone", func(t *testing.T) {
+		h := StdHandler(ReturnHandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write([]byte("partial"))
+			return errors.New("too late")
+		}), StdHandlerOptions{})
+
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, newRequest())
+
+		if res.Code != http.StatusAccepted {
+			t.Errorf("expected the already-written status %d to be left alone, got %d", http.StatusAccepted, res.Code)
+		}
+
+		if res.Body.String() != "partial" {
+			t.Errorf("expected the already-written body to be left alone, got %q", res.Body.String())
+		}
+	})
+
+	t.Run("DefaultCode overrides the fallback status", func(t *testing.T) {
+		h := StdHandler(ReturnHandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			return errors.New("boom")
+		}), StdHandlerOptions{DefaultCode: http.StatusBadGateway})
+
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, newRequest())
+
+		if res.Code != http.StatusBadGateway {
+			t.Errorf("expected status %d, got %d", http.StatusBadGateway, res.Code)
+		}
+	})
+
+	t.Run("nil error with no context error writes nothing", func(t *testing.T) {
+		h := StdHandler(ReturnHandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+			return nil
+		}), StdHandlerOptions{})
+
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, newRequest())
+
+		if res.Code != http.StatusOK {
+			t.Errorf("expected the recorder's default status %d, got %d", http.StatusOK, res.Code)
+		}
+
+		if res.Body.Len() != 0 {
+			t.Errorf("expected no body to be written, got %q", res.Body.String())
+		}
+	})
+}
@@ -2,6 +2,17 @@ package webio
 
 // The original work was derived from go-chi's middleware, source:
 // https://github.com/go-chi/chi/tree/master/middleware/wrap_writer.go
+//
+// The optional-interface dispatch below (newWriter picking one of the
+// comboNN types in writer_combos.go) follows the combinatorial wrapping
+// technique used by https://github.com/felixge/httpsnoop: rather than have a
+// single concrete type unconditionally implement http.Flusher,
+// http.Hijacker, io.ReaderFrom, http.Pusher and http.CloseNotifier (and
+// panic or lie when the wrapped writer doesn't actually support one of
+// them), we compute which of those the wrapped writer implements once, up
+// front, and return a type that implements exactly that set. A type
+// assertion against the result therefore behaves identically whether or not
+// it goes through CustomWriter.
 
 import (
 	"bufio"
@@ -31,6 +42,39 @@ type CustomWriter interface {
 	TeeWriter(tee io.Writer)
 }
 
+// WriteHeaderFunc is the signature of http.ResponseWriter.WriteHeader.
+type WriteHeaderFunc func(code int)
+
+// WriteFunc is the signature of http.ResponseWriter.Write.
+type WriteFunc func(b []byte) (int, error)
+
+// FlushFunc is the signature of http.Flusher.Flush.
+type FlushFunc func()
+
+// HijackFunc is the signature of http.Hijacker.Hijack.
+type HijackFunc func() (net.Conn, *bufio.ReadWriter, error)
+
+// ReadFromFunc is the signature of io.ReaderFrom.ReadFrom.
+type ReadFromFunc func(src io.Reader) (int64, error)
+
+// PushFunc is the signature of http.Pusher.Push.
+type PushFunc func(target string, opts *http.PushOptions) error
+
+// Hooks lets a caller intercept individual operations on the CustomWriter
+// without having to fork or re-implement it. Each field wraps the
+// corresponding operation's func; leave a field nil to leave that operation
+// untouched. Hooks only fire for operations the wrapped http.ResponseWriter
+// actually supports, e.g. Hijack is never called if the underlying writer
+// isn't an http.Hijacker.
+type Hooks struct {
+	WriteHeader func(WriteHeaderFunc) WriteHeaderFunc
+	Write       func(WriteFunc) WriteFunc
+	Flush       func(FlushFunc) FlushFunc
+	Hijack      func(HijackFunc) HijackFunc
+	ReadFrom    func(ReadFromFunc) ReadFromFunc
+	Push        func(PushFunc) PushFunc
+}
+
 type custom struct {
 	w           http.ResponseWriter
 	start       time.Time
@@ -39,55 +83,109 @@ type custom struct {
 	wroteHeader bool
 	headerName  string
 	teeWriter   io.Writer
+
+	writeHeaderFunc WriteHeaderFunc
+	writeFunc       WriteFunc
+	flushFunc       FlushFunc
+	hijackFunc      HijackFunc
+	readFromFunc    ReadFromFunc
+	pushFunc        PushFunc
 }
 
-func newWriter(w http.ResponseWriter, protoMajor int, headerName string) http.ResponseWriter {
-	_, fl := w.(http.Flusher)
+// NewWriter wraps w in a CustomWriter that tracks the response status code
+// and duration, adds a response-time header, and implements exactly the
+// optional interfaces (http.Flusher, http.Hijacker, io.ReaderFrom,
+// http.Pusher, http.CloseNotifier) that w itself implements. headerName
+// overrides the header used to report the response time; pass "" to use
+// X-Response-Time. hooks lets the caller intercept any of the wrapped
+// operations; pass the zero value for none.
+func NewWriter(w http.ResponseWriter, headerName string, hooks Hooks) CustomWriter {
+	return newWriter(w, headerName, hooks).(CustomWriter)
+}
 
+// newWriter wraps w so that it tracks the response status code and
+// duration, adds a response-time header, and implements exactly the
+// optional interfaces (http.Flusher, http.Hijacker, io.ReaderFrom,
+// http.Pusher, http.CloseNotifier) that w itself implements. hooks, if
+// non-zero, lets the caller intercept any of the wrapped operations.
+func newWriter(w http.ResponseWriter, headerName string, hooks Hooks) http.ResponseWriter {
 	if headerName == "" {
 		headerName = defaultResponseTime
 	}
 
-	tw := custom{
+	t := &custom{
 		w:          w,
 		start:      time.Now(),
 		headerName: headerName,
 	}
 
-	if protoMajor == 2 {
-		_, ps := w.(http.Pusher)
-		if fl && ps {
-			return &http2Writer{tw}
+	t.writeHeaderFunc = t.rawWriteHeader
+	if hooks.WriteHeader != nil {
+		t.writeHeaderFunc = hooks.WriteHeader(t.writeHeaderFunc)
+	}
+
+	t.writeFunc = t.rawWrite
+	if hooks.Write != nil {
+		t.writeFunc = hooks.Write(t.writeFunc)
+	}
+
+	id := 0
+
+	if _, ok := w.(http.Flusher); ok {
+		id |= 1
+		t.flushFunc = t.rawFlush
+		if hooks.Flush != nil {
+			t.flushFunc = hooks.Flush(t.flushFunc)
+		}
+	}
+
+	if _, ok := w.(http.Hijacker); ok {
+		id |= 2
+		t.hijackFunc = t.rawHijack
+		if hooks.Hijack != nil {
+			t.hijackFunc = hooks.Hijack(t.hijackFunc)
+		}
+	}
+
+	if _, ok := w.(io.ReaderFrom); ok {
+		id |= 4
+		t.readFromFunc = t.rawReadFrom
+		if hooks.ReadFrom != nil {
+			t.readFromFunc = hooks.ReadFrom(t.readFromFunc)
 		}
-	} else {
-		_, hj := w.(http.Hijacker)
-		_, rf := w.(io.ReaderFrom)
-		if fl && hj && rf {
-			return &httpWriter{tw}
+	}
+
+	if _, ok := w.(http.Pusher); ok {
+		id |= 8
+		t.pushFunc = t.rawPush
+		if hooks.Push != nil {
+			t.pushFunc = hooks.Push(t.pushFunc)
 		}
 	}
-	if fl {
-		return &flushWriter{tw}
+
+	if _, ok := w.(http.CloseNotifier); ok { //nolint:staticcheck // CloseNotifier is deprecated but still used by some writers.
+		id |= 16
 	}
 
-	return &tw
+	return pick(id, t)
 }
 
-func (t *custom) WriteHeader(code int) {
-	if !t.wroteHeader {
-		t.wroteHeader = true
-		t.duration = time.Since(t.start)
-		t.code = code
+func (t *custom) rawWriteHeader(code int) {
+	if t.wroteHeader {
+		return
+	}
+	t.wroteHeader = true
+	t.duration = time.Since(t.start)
+	t.code = code
 
-		// write the response time header
-		dur := int(t.duration.Milliseconds())
-		t.Header().Add(t.headerName, strconv.Itoa(dur)+"ms")
+	// write the response time header
+	dur := int(t.duration.Milliseconds())
+	t.Header().Add(t.headerName, strconv.Itoa(dur)+"ms")
 
-		t.w.WriteHeader(code)
-	}
+	t.w.WriteHeader(code)
 }
 
-func (t *custom) Write(buf []byte) (int, error) {
+func (t *custom) rawWrite(buf []byte) (int, error) {
 	if !t.wroteHeader {
 		t.WriteHeader(http.StatusOK)
 	}
@@ -106,6 +204,34 @@ func (t *custom) Write(buf []byte) (int, error) {
 	return n, err
 }
 
+func (t *custom) rawFlush() {
+	t.wroteHeader = true
+	t.w.(http.Flusher).Flush()
+}
+
+func (t *custom) rawHijack() (net.Conn, *bufio.ReadWriter, error) {
+	return t.w.(http.Hijacker).Hijack()
+}
+
+func (t *custom) rawReadFrom(src io.Reader) (int64, error) {
+	if !t.wroteHeader {
+		t.WriteHeader(http.StatusOK)
+	}
+	return t.w.(io.ReaderFrom).ReadFrom(src)
+}
+
+func (t *custom) rawPush(target string, opts *http.PushOptions) error {
+	return t.w.(http.Pusher).Push(target, opts)
+}
+
+func (t *custom) WriteHeader(code int) {
+	t.writeHeaderFunc(code)
+}
+
+func (t *custom) Write(buf []byte) (int, error) {
+	return t.writeFunc(buf)
+}
+
 func (t *custom) Header() http.Header {
 	return t.w.Header()
 }
@@ -122,57 +248,22 @@ func (t *custom) TeeWriter(w io.Writer) {
 	t.teeWriter = w
 }
 
-type flushWriter struct {
-	custom
-}
-
-func (f *flushWriter) Flush() {
-	f.wroteHeader = true
-	fl := f.custom.w.(http.Flusher)
-	fl.Flush()
-}
+type flusher struct{ *custom }
 
-type httpWriter struct {
-	custom
-}
+func (f flusher) Flush() { f.flushFunc() }
 
-func (h1 *httpWriter) Flush() {
-	h1.wroteHeader = true
-	fl := h1.custom.w.(http.Flusher)
-	fl.Flush()
-}
+type hijacker struct{ *custom }
 
-func (h1 *httpWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	hj := h1.custom.w.(http.Hijacker)
-	return hj.Hijack()
-}
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) { return h.hijackFunc() }
 
-func (h1 *httpWriter) ReadFrom(r io.Reader) (int64, error) {
-	rf := h1.custom.w.(io.ReaderFrom)
-	if !h1.wroteHeader {
-		h1.WriteHeader(http.StatusOK)
-	}
-	return rf.ReadFrom(r)
-}
+type readerFrom struct{ *custom }
 
-type http2Writer struct {
-	custom
-}
+func (r readerFrom) ReadFrom(src io.Reader) (int64, error) { return r.readFromFunc(src) }
 
-func (h2 *http2Writer) Push(target string, opts *http.PushOptions) error {
-	return h2.custom.w.(http.Pusher).Push(target, opts)
-}
+type pusher struct{ *custom }
 
-func (h2 *http2Writer) Flush() {
-	h2.wroteHeader = true
-	fl := h2.custom.w.(http.Flusher)
-	fl.Flush()
-}
+func (p pusher) Push(target string, opts *http.PushOptions) error { return p.pushFunc(target, opts) }
 
-// static tests
-var _ http.Flusher = &httpWriter{}
-var _ http.Flusher = &http2Writer{}
+type closeNotifier struct{ *custom }
 
-var _ http.Pusher = &http2Writer{}
-var _ http.Hijacker = &httpWriter{}
-var _ io.ReaderFrom = &httpWriter{}
+func (c closeNotifier) CloseNotify() <-chan bool { return c.w.(http.CloseNotifier).CloseNotify() } //nolint:staticcheck
@@ -0,0 +1,297 @@
+package webio
+
+// This file is mechanically derived from the bit-combinations of the
+// optional interfaces a http.ResponseWriter may implement (http.Flusher,
+// http.Hijacker, io.ReaderFrom, http.Pusher, http.CloseNotifier). Each
+// comboNN type embeds exactly the mixins for the bits set in NN, so that a
+// type assertion against the returned http.ResponseWriter only succeeds for
+// interfaces the wrapped writer actually supports. See newWriter in
+// writer.go for how the bitmask is computed and dispatched.
+//
+// bit 1<<0 = http.Flusher, 1<<1 = http.Hijacker, 1<<2 = io.ReaderFrom,
+// 1<<3 = http.Pusher, 1<<4 = http.CloseNotifier
+
+import "net/http"
+
+type combo00 struct {
+	*custom
+}
+
+type combo01 struct {
+	*custom
+	flusher
+}
+
+type combo02 struct {
+	*custom
+	hijacker
+}
+
+type combo03 struct {
+	*custom
+	flusher
+	hijacker
+}
+
+type combo04 struct {
+	*custom
+	readerFrom
+}
+
+type combo05 struct {
+	*custom
+	flusher
+	readerFrom
+}
+
+type combo06 struct {
+	*custom
+	hijacker
+	readerFrom
+}
+
+type combo07 struct {
+	*custom
+	flusher
+	hijacker
+	readerFrom
+}
+
+type combo08 struct {
+	*custom
+	pusher
+}
+
+type combo09 struct {
+	*custom
+	flusher
+	pusher
+}
+
+type combo10 struct {
+	*custom
+	hijacker
+	pusher
+}
+
+type combo11 struct {
+	*custom
+	flusher
+	hijacker
+	pusher
+}
+
+type combo12 struct {
+	*custom
+	readerFrom
+	pusher
+}
+
+type combo13 struct {
+	*custom
+	flusher
+	readerFrom
+	pusher
+}
+
+type combo14 struct {
+	*custom
+	hijacker
+	readerFrom
+	pusher
+}
+
+type combo15 struct {
+	*custom
+	flusher
+	hijacker
+	readerFrom
+	pusher
+}
+
+type combo16 struct {
+	*custom
+	closeNotifier
+}
+
+type combo17 struct {
+	*custom
+	flusher
+	closeNotifier
+}
+
+type combo18 struct {
+	*custom
+	hijacker
+	closeNotifier
+}
+
+type combo19 struct {
+	*custom
+	flusher
+	hijacker
+	closeNotifier
+}
+
+type combo20 struct {
+	*custom
+	readerFrom
+	closeNotifier
+}
+
+type combo21 struct {
+	*custom
+	flusher
+	readerFrom
+	closeNotifier
+}
+
+type combo22 struct {
+	*custom
+	hijacker
+	readerFrom
+	closeNotifier
+}
+
+type combo23 struct {
+	*custom
+	flusher
+	hijacker
+	readerFrom
+	closeNotifier
+}
+
+type combo24 struct {
+	*custom
+	pusher
+	closeNotifier
+}
+
+type combo25 struct {
+	*custom
+	flusher
+	pusher
+	closeNotifier
+}
+
+type combo26 struct {
+	*custom
+	hijacker
+	pusher
+	closeNotifier
+}
+
+type combo27 struct {
+	*custom
+	flusher
+	hijacker
+	pusher
+	closeNotifier
+}
+
+type combo28 struct {
+	*custom
+	readerFrom
+	pusher
+	closeNotifier
+}
+
+type combo29 struct {
+	*custom
+	flusher
+	readerFrom
+	pusher
+	closeNotifier
+}
+
+type combo30 struct {
+	*custom
+	hijacker
+	readerFrom
+	pusher
+	closeNotifier
+}
+
+type combo31 struct {
+	*custom
+	flusher
+	hijacker
+	readerFrom
+	pusher
+	closeNotifier
+}
+
+// pick returns the http.ResponseWriter combo type matching the given bitmask
+// of optional interfaces, so that callers type-asserting against the result
+// only see the interfaces the wrapped writer actually implements.
+func pick(id int, t *custom) http.ResponseWriter {
+	switch id {
+	case 0:
+		return &combo00{custom: t}
+	case 1:
+		return &combo01{custom: t, flusher: flusher{custom: t}}
+	case 2:
+		return &combo02{custom: t, hijacker: hijacker{custom: t}}
+	case 3:
+		return &combo03{custom: t, flusher: flusher{custom: t}, hijacker: hijacker{custom: t}}
+	case 4:
+		return &combo04{custom: t, readerFrom: readerFrom{custom: t}}
+	case 5:
+		return &combo05{custom: t, flusher: flusher{custom: t}, readerFrom: readerFrom{custom: t}}
+	case 6:
+		return &combo06{custom: t, hijacker: hijacker{custom: t}, readerFrom: readerFrom{custom: t}}
+	case 7:
+		return &combo07{custom: t, flusher: flusher{custom: t}, hijacker: hijacker{custom: t}, readerFrom: readerFrom{custom: t}}
+	case 8:
+		return &combo08{custom: t, pusher: pusher{custom: t}}
+	case 9:
+		return &combo09{custom: t, flusher: flusher{custom: t}, pusher: pusher{custom: t}}
+	case 10:
+		return &combo10{custom: t, hijacker: hijacker{custom: t}, pusher: pusher{custom: t}}
+	case 11:
+		return &combo11{custom: t, flusher: flusher{custom: t}, hijacker: hijacker{custom: t}, pusher: pusher{custom: t}}
+	case 12:
+		return &combo12{custom: t, readerFrom: readerFrom{custom: t}, pusher: pusher{custom: t}}
+	case 13:
+		return &combo13{custom: t, flusher: flusher{custom: t}, readerFrom: readerFrom{custom: t}, pusher: pusher{custom: t}}
+	case 14:
+		return &combo14{custom: t, hijacker: hijacker{custom: t}, readerFrom: readerFrom{custom: t}, pusher: pusher{custom: t}}
+	case 15:
+		return &combo15{custom: t, flusher: flusher{custom: t}, hijacker: hijacker{custom: t}, readerFrom: readerFrom{custom: t}, pusher: pusher{custom: t}}
+	case 16:
+		return &combo16{custom: t, closeNotifier: closeNotifier{custom: t}}
+	case 17:
+		return &combo17{custom: t, flusher: flusher{custom: t}, closeNotifier: closeNotifier{custom: t}}
+	case 18:
+		return &combo18{custom: t, hijacker: hijacker{custom: t}, closeNotifier: closeNotifier{custom: t}}
+	case 19:
+		return &combo19{custom: t, flusher: flusher{custom: t}, hijacker: hijacker{custom: t}, closeNotifier: closeNotifier{custom: t}}
+	case 20:
+		return &combo20{custom: t, readerFrom: readerFrom{custom: t}, closeNotifier: closeNotifier{custom: t}}
+	case 21:
+		return &combo21{custom: t, flusher: flusher{custom: t}, readerFrom: readerFrom{custom: t}, closeNotifier: closeNotifier{custom: t}}
+	case 22:
+		return &combo22{custom: t, hijacker: hijacker{custom: t}, readerFrom: readerFrom{custom: t}, closeNotifier: closeNotifier{custom: t}}
+	case 23:
+		return &combo23{custom: t, flusher: flusher{custom: t}, hijacker: hijacker{custom: t}, readerFrom: readerFrom{custom: t}, closeNotifier: closeNotifier{custom: t}}
+	case 24:
+		return &combo24{custom: t, pusher: pusher{custom: t}, closeNotifier: closeNotifier{custom: t}}
+	case 25:
+		return &combo25{custom: t, flusher: flusher{custom: t}, pusher: pusher{custom: t}, closeNotifier: closeNotifier{custom: t}}
+	case 26:
+		return &combo26{custom: t, hijacker: hijacker{custom: t}, pusher: pusher{custom: t}, closeNotifier: closeNotifier{custom: t}}
+	case 27:
+		return &combo27{custom: t, flusher: flusher{custom: t}, hijacker: hijacker{custom: t}, pusher: pusher{custom: t}, closeNotifier: closeNotifier{custom: t}}
+	case 28:
+		return &combo28{custom: t, readerFrom: readerFrom{custom: t}, pusher: pusher{custom: t}, closeNotifier: closeNotifier{custom: t}}
+	case 29:
+		return &combo29{custom: t, flusher: flusher{custom: t}, readerFrom: readerFrom{custom: t}, pusher: pusher{custom: t}, closeNotifier: closeNotifier{custom: t}}
+	case 30:
+		return &combo30{custom: t, hijacker: hijacker{custom: t}, readerFrom: readerFrom{custom: t}, pusher: pusher{custom: t}, closeNotifier: closeNotifier{custom: t}}
+	case 31:
+		return &combo31{custom: t, flusher: flusher{custom: t}, hijacker: hijacker{custom: t}, readerFrom: readerFrom{custom: t}, pusher: pusher{custom: t}, closeNotifier: closeNotifier{custom: t}}
+	default:
+		return t
+	}
+}
+
@@ -0,0 +1,236 @@
+package webio
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// baseWriter is a minimal http.ResponseWriter implementing none of the
+// optional interfaces, so it can be embedded into fakes that add exactly
+// the ones under test.
+type baseWriter struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func newBaseWriter() *baseWriter {
+	return &baseWriter{header: make(http.Header)}
+}
+
+func (w *baseWriter) Header() http.Header         { return w.header }
+func (w *baseWriter) WriteHeader(code int)        { w.code = code }
+func (w *baseWriter) Write(p []byte) (int, error) { return w.body.Write(p) }
+
+type flushingWriter struct {
+	*baseWriter
+	flushed bool
+}
+
+func (w *flushingWriter) Flush() { w.flushed = true }
+
+var errHijacked = errors.New("hijacked")
+
+type hijackingWriter struct {
+	*baseWriter
+	hijacked bool
+}
+
+func (w *hijackingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return nil, nil, errHijacked
+}
+
+type closeNotifyingWriter struct {
+	*baseWriter
+	ch chan bool
+}
+
+func (w *closeNotifyingWriter) CloseNotify() <-chan bool { return w.ch }
+
+// fullWriter implements every optional interface NewWriter knows about.
+type fullWriter struct {
+	*baseWriter
+	ch       chan bool
+	flushed  bool
+	hijacked bool
+	pushed   bool
+	readFrom bool
+}
+
+func (w *fullWriter) Flush()                   { w.flushed = true }
+func (w *fullWriter) CloseNotify() <-chan bool { return w.ch }
+
+func (w *fullWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return nil, nil, nil
+}
+
+func (w *fullWriter) Push(target string, opts *http.PushOptions) error {
+	w.pushed = true
+	return nil
+}
+
+func (w *fullWriter) ReadFrom(src io.Reader) (int64, error) {
+	w.readFrom = true
+	return 0, nil
+}
+
+func TestNewWriterInterfacePassthrough(t *testing.T) {
+	cases := []struct {
+		name              string
+		w                 http.ResponseWriter
+		wantFlusher       bool
+		wantHijacker      bool
+		wantReaderFrom    bool
+		wantPusher        bool
+		wantCloseNotifier bool
+	}{
+		{
+			name: "a plain writer exposes no optional interfaces",
+			w:    newBaseWriter(),
+		},
+		{
+			name:        "a flusher-only writer only exposes http.Flusher",
+			w:           &flushingWriter{baseWriter: newBaseWriter()},
+			wantFlusher: true,
+		},
+		{
+			name:         "a hijacker-only writer only exposes http.Hijacker",
+			w:            &hijackingWriter{baseWriter: newBaseWriter()},
+			wantHijacker: true,
+		},
+		{
+			name:              "a CloseNotifier-only writer only exposes http.CloseNotifier",
+			w:                 &closeNotifyingWriter{baseWriter: newBaseWriter(), ch: make(chan bool)},
+			wantCloseNotifier: true,
+		},
+		{
+			name:              "a writer implementing every optional interface exposes all of them",
+			w:                 &fullWriter{baseWriter: newBaseWriter(), ch: make(chan bool)},
+			wantFlusher:       true,
+			wantHijacker:      true,
+			wantReaderFrom:    true,
+			wantPusher:        true,
+			wantCloseNotifier: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ww := NewWriter(c.w, "", Hooks{})
+
+			if _, ok := ww.(http.Flusher); ok != c.wantFlusher {
+				t.Errorf("http.Flusher assertion = %v, want %v", ok, c.wantFlusher)
+			}
+			if _, ok := ww.(http.Hijacker); ok != c.wantHijacker {
+				t.Errorf("http.Hijacker assertion = %v, want %v", ok, c.wantHijacker)
+			}
+			if _, ok := ww.(io.ReaderFrom); ok != c.wantReaderFrom {
+				t.Errorf("io.ReaderFrom assertion = %v, want %v", ok, c.wantReaderFrom)
+			}
+			if _, ok := ww.(http.Pusher); ok != c.wantPusher {
+				t.Errorf("http.Pusher assertion = %v, want %v", ok, c.wantPusher)
+			}
+			if _, ok := ww.(http.CloseNotifier); ok != c.wantCloseNotifier { //nolint:staticcheck
+				t.Errorf("http.CloseNotifier assertion = %v, want %v", ok, c.wantCloseNotifier)
+			}
+		})
+	}
+}
+
+func TestNewWriterHijackPassesThrough(t *testing.T) {
+	inner := &hijackingWriter{baseWriter: newBaseWriter()}
+	ww := NewWriter(inner, "", Hooks{})
+
+	hj, ok := ww.(http.Hijacker)
+	if !ok {
+		t.Fatal("expected the combo to implement http.Hijacker")
+	}
+
+	_, _, err := hj.Hijack()
+	if !inner.hijacked {
+		t.Error("expected Hijack to reach the underlying writer")
+	}
+	if !errors.Is(err, errHijacked) {
+		t.Errorf("expected the underlying writer's error to pass through, got %v", err)
+	}
+}
+
+func TestNewWriterCloseNotifyPassesThrough(t *testing.T) {
+	ch := make(chan bool, 1)
+	inner := &closeNotifyingWriter{baseWriter: newBaseWriter(), ch: ch}
+	ww := NewWriter(inner, "", Hooks{})
+
+	cn, ok := ww.(http.CloseNotifier) //nolint:staticcheck
+	if !ok {
+		t.Fatal("expected the combo to implement http.CloseNotifier")
+	}
+
+	ch <- true
+
+	select {
+	case v := <-cn.CloseNotify():
+		if !v {
+			t.Error("expected the notified value to pass through unchanged")
+		}
+	case <-time.After(time.Second):
+		t.Error("timed out waiting for CloseNotify to relay the underlying channel")
+	}
+}
+
+func TestNewWriterHooks(t *testing.T) {
+	t.Run("WriteHeader hook intercepts the status code", func(t *testing.T) {
+		inner := newBaseWriter()
+		var seen int
+
+		ww := NewWriter(inner, "", Hooks{
+			WriteHeader: func(next WriteHeaderFunc) WriteHeaderFunc {
+				return func(code int) {
+					seen = code
+					next(code)
+				}
+			},
+		})
+
+		ww.WriteHeader(http.StatusTeapot)
+
+		if seen != http.StatusTeapot {
+			t.Errorf("expected the hook to observe status %d, got %d", http.StatusTeapot, seen)
+		}
+		if inner.code != http.StatusTeapot {
+			t.Errorf("expected the underlying writer to still receive status %d, got %d", http.StatusTeapot, inner.code)
+		}
+	})
+
+	t.Run("Write hook intercepts the written bytes", func(t *testing.T) {
+		inner := newBaseWriter()
+		var seen []byte
+
+		ww := NewWriter(inner, "", Hooks{
+			Write: func(next WriteFunc) WriteFunc {
+				return func(p []byte) (int, error) {
+					seen = append(seen, p...)
+					return next(p)
+				}
+			},
+		})
+
+		if _, err := ww.Write([]byte("hello")); err != nil {
+			t.Fatalf("unexpected error from Write: %v", err)
+		}
+
+		if string(seen) != "hello" {
+			t.Errorf("expected the hook to observe %q, got %q", "hello", seen)
+		}
+		if inner.body.String() != "hello" {
+			t.Errorf("expected the underlying writer to still receive %q, got %q", "hello", inner.body.String())
+		}
+	})
+}